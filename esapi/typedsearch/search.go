@@ -0,0 +1,158 @@
+// Package typedsearch wraps esapi.Search with a generics-based decode step
+// so callers don't have to unwrap map[string]interface{} response bodies by
+// hand.
+package typedsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7"
+)
+
+// Hit is a single search result, decoded into T.
+type Hit[T any] struct {
+	ID     string
+	Index  string
+	Score  float64
+	Source T
+}
+
+// SearchResult is the decoded form of an Elasticsearch _search response.
+type SearchResult[T any] struct {
+	Took      time.Duration
+	TotalHits int64
+	Hits      []Hit[T]
+}
+
+// ESError is returned when the cluster responds with an error body. It
+// lets callers branch on Type/Reason instead of having to sniff
+// res.IsError() and unwrap the error map themselves.
+type ESError struct {
+	Status int
+	Type   string
+	Reason string
+}
+
+func (e *ESError) Error() string {
+	return fmt.Sprintf("[%d] %s: %s", e.Status, e.Type, e.Reason)
+}
+
+// Search runs query against index and decodes the response into a
+// SearchResult[T]. query may be a map[string]interface{}, a struct with
+// JSON tags, or anything already shaped like an io.Reader (e.g. a
+// bytes.Buffer the caller built themselves).
+func Search[T any](ctx context.Context, es *elasticsearch.Client, index string, query interface{}) (*SearchResult[T], error) {
+	body, err := toReader(query)
+	if err != nil {
+		return nil, fmt.Errorf("typedsearch: encoding query: %w", err)
+	}
+
+	res, err := es.Search(
+		es.Search.WithContext(ctx),
+		es.Search.WithIndex(index),
+		es.Search.WithBody(body),
+		es.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, decodeError(res.StatusCode, res.Body)
+	}
+
+	var raw rawResponse[T]
+	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("typedsearch: decoding response: %w", err)
+	}
+
+	total, err := raw.Hits.Total.value()
+	if err != nil {
+		return nil, fmt.Errorf("typedsearch: decoding hits.total: %w", err)
+	}
+
+	result := &SearchResult[T]{
+		Took:      time.Duration(raw.Took) * time.Millisecond,
+		TotalHits: total,
+		Hits:      make([]Hit[T], 0, len(raw.Hits.Hits)),
+	}
+	for _, h := range raw.Hits.Hits {
+		result.Hits = append(result.Hits, Hit[T]{
+			ID:     h.ID,
+			Index:  h.Index,
+			Score:  h.Score,
+			Source: h.Source,
+		})
+	}
+	return result, nil
+}
+
+func toReader(query interface{}) (io.Reader, error) {
+	switch q := query.(type) {
+	case io.Reader:
+		return q, nil
+	default:
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(q); err != nil {
+			return nil, err
+		}
+		return &buf, nil
+	}
+}
+
+func decodeError(status int, body io.Reader) error {
+	var e struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(body).Decode(&e); err != nil {
+		return fmt.Errorf("typedsearch: decoding error body: %w", err)
+	}
+	return &ESError{Status: status, Type: e.Error.Type, Reason: e.Error.Reason}
+}
+
+type rawResponse[T any] struct {
+	Took int64 `json:"took"`
+	Hits struct {
+		Total totalHits `json:"total"`
+		Hits  []struct {
+			ID     string  `json:"_id"`
+			Index  string  `json:"_index"`
+			Score  float64 `json:"_score"`
+			Source T       `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// totalHits accepts both the ES7+ object form ({"value": N, "relation": "eq"})
+// and the legacy ES6 bare-number form.
+type totalHits struct {
+	raw json.RawMessage
+}
+
+func (t *totalHits) UnmarshalJSON(b []byte) error {
+	t.raw = append([]byte(nil), b...)
+	return nil
+}
+
+func (t totalHits) value() (int64, error) {
+	var n int64
+	if err := json.Unmarshal(t.raw, &n); err == nil {
+		return n, nil
+	}
+	var obj struct {
+		Value int64 `json:"value"`
+	}
+	if err := json.Unmarshal(t.raw, &obj); err != nil {
+		return 0, err
+	}
+	return obj.Value, nil
+}
@@ -0,0 +1,107 @@
+package typedsearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7"
+)
+
+type doc struct {
+	Username string `json:"username"`
+}
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *elasticsearch.Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	return es
+}
+
+func TestSearchDecodesES7TotalHitsForm(t *testing.T) {
+	es := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		if r.URL.Path != "/my-index/_search" {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"took": 5,
+			"hits": {
+				"total": {"value": 2, "relation": "eq"},
+				"hits": [
+					{"_id": "1", "_index": "my-index", "_score": 1.5, "_source": {"username": "alice"}},
+					{"_id": "2", "_index": "my-index", "_score": 1.0, "_source": {"username": "bob"}}
+				]
+			}
+		}`)
+	})
+
+	result, err := Search[doc](context.Background(), es, "my-index", map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}})
+	if err != nil {
+		t.Fatalf("Search: %s", err)
+	}
+
+	if result.TotalHits != 2 {
+		t.Fatalf("expected TotalHits=2, got %d", result.TotalHits)
+	}
+	if len(result.Hits) != 2 {
+		t.Fatalf("expected 2 hits, got %d", len(result.Hits))
+	}
+	if result.Hits[0].Source.Username != "alice" {
+		t.Fatalf("expected first hit's Source.Username=alice, got %q", result.Hits[0].Source.Username)
+	}
+	if result.Hits[0].ID != "1" || result.Hits[0].Index != "my-index" {
+		t.Fatalf("unexpected hit metadata: %+v", result.Hits[0])
+	}
+}
+
+func TestSearchDecodesES6TotalHitsForm(t *testing.T) {
+	es := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		if r.URL.Path != "/my-index/_search" {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"took": 1, "hits": {"total": 3, "hits": []}}`)
+	})
+
+	result, err := Search[doc](context.Background(), es, "my-index", map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Search: %s", err)
+	}
+	if result.TotalHits != 3 {
+		t.Fatalf("expected TotalHits=3 from the bare-number ES6 form, got %d", result.TotalHits)
+	}
+}
+
+func TestSearchSurfacesESError(t *testing.T) {
+	es := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		if r.URL.Path != "/my-index/_search" {
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": {"type": "search_phase_execution_exception", "reason": "boom"}}`)
+	})
+
+	_, err := Search[doc](context.Background(), es, "my-index", map[string]interface{}{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	esErr, ok := err.(*ESError)
+	if !ok {
+		t.Fatalf("expected *ESError, got %T: %s", err, err)
+	}
+	if esErr.Type != "search_phase_execution_exception" || esErr.Reason != "boom" {
+		t.Fatalf("unexpected ESError: %+v", esErr)
+	}
+}
@@ -0,0 +1,328 @@
+// Package esutil provides higher-level helpers built on top of esapi that
+// are common enough to not be left as an exercise for every caller.
+package esutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/esapi"
+	"github.com/elastic/go-elasticsearch/v7"
+)
+
+// BulkIndexerItem is a single document to be indexed, updated, or deleted as
+// part of a bulk request.
+type BulkIndexerItem struct {
+	// Action is one of "index", "create", "update", or "delete". Defaults
+	// to "index" when empty.
+	Action     string
+	Index      string
+	DocumentID string
+	Body       io.Reader
+
+	// OnSuccess is called with the per-item response once the containing
+	// batch has been flushed and the item's action succeeded.
+	OnSuccess func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem)
+	// OnFailure is called instead of OnSuccess when the item's action
+	// failed, or with a non-nil err if the batch could not be sent at all or
+	// its _bulk response could not be decoded.
+	OnFailure func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem, err error)
+}
+
+// BulkIndexerResponseItem is the per-item portion of a _bulk response.
+type BulkIndexerResponseItem struct {
+	Index  string `json:"_index"`
+	ID     string `json:"_id"`
+	Status int    `json:"status"`
+	Result string `json:"result"`
+	Error  struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// BulkIndexerStats reports cumulative counters for a BulkIndexer.
+type BulkIndexerStats struct {
+	NumAdded    uint64
+	NumFlushed  uint64
+	NumFailed   uint64
+	NumIndexed  uint64
+	NumRequests uint64
+}
+
+// BulkIndexerConfig configures a BulkIndexer.
+type BulkIndexerConfig struct {
+	Client *elasticsearch.Client
+
+	// NumWorkers is the number of goroutines consuming queued items.
+	// Defaults to 1.
+	NumWorkers int
+	// FlushBytes is the NDJSON payload size, in bytes, at which a worker
+	// flushes its in-progress batch. Defaults to 5MB.
+	FlushBytes int
+	// FlushInterval is the max time a worker holds a partial batch before
+	// flushing it regardless of size. Defaults to 30s.
+	FlushInterval time.Duration
+
+	// OnError is called for transport-level errors that prevent a batch
+	// from being sent at all, and for errors decoding a batch's _bulk
+	// response (as opposed to per-item failures, which are reported via the
+	// item's own OnFailure).
+	OnError func(ctx context.Context, err error)
+}
+
+// queuedItem pairs a BulkIndexerItem with the context its Add call carried,
+// so the eventual _bulk request can be made with that context instead of a
+// detached one.
+type queuedItem struct {
+	BulkIndexerItem
+	ctx context.Context
+}
+
+// BulkIndexer batches documents and submits them to the _bulk endpoint
+// across a pool of worker goroutines, flushing each worker's batch on
+// whichever of FlushBytes or FlushInterval is reached first.
+type BulkIndexer struct {
+	config BulkIndexerConfig
+
+	queue   chan queuedItem
+	wg      sync.WaitGroup // worker goroutines
+	addWG   sync.WaitGroup // Add calls in flight
+	closing chan struct{}  // closed by Close to unblock Adds waiting on queue
+	stats   BulkIndexerStats
+
+	mu     sync.Mutex // guards stats and closed
+	closed bool
+}
+
+// NewBulkIndexer creates a BulkIndexer and starts its worker goroutines.
+func NewBulkIndexer(config BulkIndexerConfig) (*BulkIndexer, error) {
+	if config.Client == nil {
+		return nil, fmt.Errorf("esutil: BulkIndexerConfig.Client must not be nil")
+	}
+	if config.NumWorkers <= 0 {
+		config.NumWorkers = 1
+	}
+	if config.FlushBytes <= 0 {
+		config.FlushBytes = 5 * 1024 * 1024
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 30 * time.Second
+	}
+
+	bi := &BulkIndexer{
+		config:  config,
+		queue:   make(chan queuedItem),
+		closing: make(chan struct{}),
+	}
+
+	for i := 0; i < config.NumWorkers; i++ {
+		bi.wg.Add(1)
+		go bi.worker()
+	}
+
+	return bi, nil
+}
+
+// Add queues item for indexing. It blocks until a worker accepts it, ctx is
+// done, or the indexer is closed. ctx is threaded through to the eventual
+// _bulk request, so it can also be used to correlate that request with a
+// parent span.
+//
+// Add registers itself in addWG before releasing the indexer's mutex, so
+// Close -- which only ever touches the mutex long enough to flip closed --
+// can wait for every in-flight Add to finish before it closes the queue,
+// instead of blocking on the mutex itself for the duration of the send.
+func (bi *BulkIndexer) Add(ctx context.Context, item BulkIndexerItem) error {
+	if item.Action == "" {
+		item.Action = "index"
+	}
+
+	bi.mu.Lock()
+	if bi.closed {
+		bi.mu.Unlock()
+		return fmt.Errorf("esutil: BulkIndexer is closed")
+	}
+	bi.addWG.Add(1)
+	bi.stats.NumAdded++
+	bi.mu.Unlock()
+	defer bi.addWG.Done()
+
+	select {
+	case bi.queue <- queuedItem{BulkIndexerItem: item, ctx: ctx}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-bi.closing:
+		return fmt.Errorf("esutil: BulkIndexer is closed")
+	}
+}
+
+// Close stops accepting new items, flushes any in-flight batches, and waits
+// for all workers to finish, or ctx to be done, whichever comes first.
+func (bi *BulkIndexer) Close(ctx context.Context) error {
+	bi.mu.Lock()
+	if bi.closed {
+		bi.mu.Unlock()
+		return nil
+	}
+	bi.closed = true
+	bi.mu.Unlock()
+	close(bi.closing)
+
+	done := make(chan struct{})
+	go func() {
+		bi.addWG.Wait()
+		close(bi.queue)
+		bi.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the indexer's cumulative counters.
+func (bi *BulkIndexer) Stats() BulkIndexerStats {
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+	return bi.stats
+}
+
+func (bi *BulkIndexer) worker() {
+	defer bi.wg.Done()
+
+	var (
+		batch     []queuedItem
+		buf       bytes.Buffer
+		flushTick = time.NewTicker(bi.config.FlushInterval)
+	)
+	defer flushTick.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		bi.send(batch, &buf)
+		batch = batch[:0]
+		buf.Reset()
+	}
+
+	for {
+		select {
+		case item, ok := <-bi.queue:
+			if !ok {
+				flush()
+				return
+			}
+			meta, _ := json.Marshal(map[string]interface{}{
+				item.Action: metaFields(item.BulkIndexerItem),
+			})
+			buf.Write(meta)
+			buf.WriteByte('\n')
+			if item.Body != nil {
+				io.Copy(&buf, item.Body)
+				buf.WriteByte('\n')
+			}
+			batch = append(batch, item)
+
+			if buf.Len() >= bi.config.FlushBytes {
+				flush()
+			}
+		case <-flushTick.C:
+			flush()
+		}
+	}
+}
+
+func metaFields(item BulkIndexerItem) map[string]interface{} {
+	m := map[string]interface{}{"_index": item.Index}
+	if item.DocumentID != "" {
+		m["_id"] = item.DocumentID
+	}
+	return m
+}
+
+// send flushes a batch, using the most recently queued item's context for
+// the _bulk request itself.
+func (bi *BulkIndexer) send(batch []queuedItem, buf *bytes.Buffer) {
+	ctx := batch[len(batch)-1].ctx
+
+	bi.mu.Lock()
+	bi.stats.NumRequests++
+	bi.mu.Unlock()
+
+	res, err := esapi.BulkRequest{Body: bytes.NewReader(buf.Bytes())}.Do(ctx, bi.config.Client)
+	if err != nil {
+		bi.mu.Lock()
+		bi.stats.NumFailed += uint64(len(batch))
+		bi.mu.Unlock()
+		if bi.config.OnError != nil {
+			bi.config.OnError(ctx, err)
+		}
+		for _, item := range batch {
+			if item.OnFailure != nil {
+				item.OnFailure(ctx, item.BulkIndexerItem, BulkIndexerResponseItem{}, err)
+			}
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	var raw struct {
+		Items []map[string]BulkIndexerResponseItem `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		bi.mu.Lock()
+		bi.stats.NumFailed += uint64(len(batch))
+		bi.mu.Unlock()
+		if bi.config.OnError != nil {
+			bi.config.OnError(ctx, err)
+		}
+		for _, item := range batch {
+			if item.OnFailure != nil {
+				item.OnFailure(ctx, item.BulkIndexerItem, BulkIndexerResponseItem{}, err)
+			}
+		}
+		return
+	}
+
+	bi.mu.Lock()
+	bi.stats.NumFlushed += uint64(len(batch))
+	bi.mu.Unlock()
+
+	for i, item := range batch {
+		if i >= len(raw.Items) {
+			break
+		}
+		var itemRes BulkIndexerResponseItem
+		for _, v := range raw.Items[i] {
+			itemRes = v
+		}
+
+		ok := itemRes.Status >= 200 && itemRes.Status < 300
+		bi.mu.Lock()
+		if ok {
+			bi.stats.NumIndexed++
+		} else {
+			bi.stats.NumFailed++
+		}
+		bi.mu.Unlock()
+
+		if ok {
+			if item.OnSuccess != nil {
+				item.OnSuccess(ctx, item.BulkIndexerItem, itemRes)
+			}
+		} else if item.OnFailure != nil {
+			item.OnFailure(ctx, item.BulkIndexerItem, itemRes, nil)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package esutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *elasticsearch.Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	return es
+}
+
+// countBulkRequests wraps handler, calling inc only for the actual _bulk
+// calls and not the client's one-time GET / product-check request.
+func countBulkRequests(inc func(), handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_bulk" {
+			inc()
+		}
+		handler(w, r)
+	}
+}
+
+func bulkOKHandler(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	lines := strings.Split(strings.TrimRight(string(body), "\n"), "\n")
+
+	items := make([]map[string]BulkIndexerResponseItem, 0, len(lines)/2)
+	for i := 0; i+1 < len(lines); i += 2 {
+		items = append(items, map[string]BulkIndexerResponseItem{
+			"index": {Status: 201, Result: "created"},
+		})
+	}
+
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": false, "items": items})
+}
+
+func TestBulkIndexerFlushesOnFlushBytes(t *testing.T) {
+	var requests int32
+	es := newTestClient(t, countBulkRequests(func() { atomic.AddInt32(&requests, 1) }, bulkOKHandler))
+
+	bi, err := NewBulkIndexer(BulkIndexerConfig{
+		Client:        es,
+		FlushBytes:    1, // flush after the very first item
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewBulkIndexer: %s", err)
+	}
+
+	var done sync.WaitGroup
+	done.Add(1)
+	if err := bi.Add(context.Background(), BulkIndexerItem{
+		Index:      "test",
+		DocumentID: "1",
+		Body:       strings.NewReader(`{"field":"value"}`),
+		OnSuccess: func(context.Context, BulkIndexerItem, BulkIndexerResponseItem) {
+			done.Done()
+		},
+	}); err != nil {
+		t.Fatalf("Add: %s", err)
+	}
+	done.Wait()
+
+	if err := bi.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Fatalf("expected exactly 1 bulk request from the size-triggered flush, got %d", got)
+	}
+}
+
+func TestBulkIndexerFlushesOnInterval(t *testing.T) {
+	var requests int32
+	es := newTestClient(t, countBulkRequests(func() { atomic.AddInt32(&requests, 1) }, bulkOKHandler))
+
+	bi, err := NewBulkIndexer(BulkIndexerConfig{
+		Client:        es,
+		FlushBytes:    1 << 20, // large enough that size never triggers
+		FlushInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewBulkIndexer: %s", err)
+	}
+
+	var done sync.WaitGroup
+	done.Add(1)
+	if err := bi.Add(context.Background(), BulkIndexerItem{
+		Index:      "test",
+		DocumentID: "1",
+		Body:       strings.NewReader(`{"field":"value"}`),
+		OnSuccess: func(context.Context, BulkIndexerItem, BulkIndexerResponseItem) {
+			done.Done()
+		},
+	}); err != nil {
+		t.Fatalf("Add: %s", err)
+	}
+	done.Wait()
+
+	if err := bi.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Fatalf("expected exactly 1 bulk request from the interval-triggered flush, got %d", got)
+	}
+}
+
+func TestBulkIndexerOnFailureCalledOnDecodeError(t *testing.T) {
+	es := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "not json")
+	})
+
+	bi, err := NewBulkIndexer(BulkIndexerConfig{Client: es, FlushBytes: 1})
+	if err != nil {
+		t.Fatalf("NewBulkIndexer: %s", err)
+	}
+
+	var failed sync.WaitGroup
+	failed.Add(1)
+	if err := bi.Add(context.Background(), BulkIndexerItem{
+		Index:      "test",
+		DocumentID: "1",
+		Body:       strings.NewReader(`{"field":"value"}`),
+		OnSuccess: func(context.Context, BulkIndexerItem, BulkIndexerResponseItem) {
+			t.Error("OnSuccess must not be called for a response that failed to decode")
+		},
+		OnFailure: func(ctx context.Context, item BulkIndexerItem, res BulkIndexerResponseItem, err error) {
+			if err == nil {
+				t.Error("expected a non-nil decode error")
+			}
+			failed.Done()
+		},
+	}); err != nil {
+		t.Fatalf("Add: %s", err)
+	}
+	failed.Wait()
+
+	if err := bi.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+}
+
+// TestBulkIndexerAddCloseRace exercises Add and Close concurrently under
+// `go test -race` to guard against sends on a closed queue channel.
+func TestBulkIndexerAddCloseRace(t *testing.T) {
+	es := newTestClient(t, bulkOKHandler)
+
+	for i := 0; i < 50; i++ {
+		bi, err := NewBulkIndexer(BulkIndexerConfig{Client: es, NumWorkers: 4})
+		if err != nil {
+			t.Fatalf("NewBulkIndexer: %s", err)
+		}
+
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				bi.Add(context.Background(), BulkIndexerItem{
+					Index: "test",
+					Body:  strings.NewReader(`{}`),
+				})
+			}()
+		}
+
+		go bi.Close(context.Background())
+		wg.Wait()
+	}
+}
+
+// TestBulkIndexerCloseRespectsContextWhileAddBlocked guards against Close
+// hanging on the indexer's mutex regardless of its own ctx: with the single
+// worker stuck in a hung _bulk request, a second Add blocks indefinitely
+// waiting for the queue (its own ctx is context.Background()), and Close
+// must still return once its ctx expires instead of waiting on that Add.
+func TestBulkIndexerCloseRespectsContextWhileAddBlocked(t *testing.T) {
+	reqStarted := make(chan struct{})
+	release := make(chan struct{})
+	es := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_bulk" {
+			close(reqStarted)
+			<-release
+		}
+		bulkOKHandler(w, r)
+	})
+	t.Cleanup(func() { close(release) })
+
+	bi, err := NewBulkIndexer(BulkIndexerConfig{
+		Client:        es,
+		NumWorkers:    1,
+		FlushInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewBulkIndexer: %s", err)
+	}
+
+	if err := bi.Add(context.Background(), BulkIndexerItem{Index: "test", Body: strings.NewReader(`{}`)}); err != nil {
+		t.Fatalf("Add: %s", err)
+	}
+
+	select {
+	case <-reqStarted:
+	case <-time.After(time.Second):
+		t.Fatal("worker never started its _bulk request")
+	}
+
+	// The single worker is now stuck in the hung request, so this Add can
+	// never be read off the unbuffered queue; it blocks until the indexer
+	// closes or its own (never-cancelled) ctx is done.
+	go bi.Add(context.Background(), BulkIndexerItem{Index: "test", Body: strings.NewReader(`{}`)})
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = bi.Close(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Close took %s; it should have returned once ctx expired", elapsed)
+	}
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected Close to return context.DeadlineExceeded, got %v", err)
+	}
+}
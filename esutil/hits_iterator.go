@@ -0,0 +1,167 @@
+package esutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// HitsIterator walks a _search response body's hits.hits array one element
+// at a time using json.Decoder.Token, instead of buffering the whole
+// response into a map[string]interface{}.
+type HitsIterator struct {
+	dec *json.Decoder
+
+	entered bool // have we descended into the hits.hits array yet?
+
+	raw json.RawMessage
+	err error
+}
+
+// NewHitsIterator returns a HitsIterator over body. body is consumed
+// lazily as Next/Decode are called and is not closed by the iterator; the
+// caller remains responsible for closing the underlying response body.
+func NewHitsIterator(body io.Reader) *HitsIterator {
+	return &HitsIterator{dec: json.NewDecoder(body)}
+}
+
+// Next advances the iterator to the next hit, descending into hits.hits on
+// the first call. It returns false once the array is exhausted or an error
+// occurs; check Err afterwards to distinguish the two.
+func (it *HitsIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if !it.entered {
+		if !it.descend() {
+			return false
+		}
+	}
+
+	if !it.dec.More() {
+		if _, err := it.dec.Token(); err != nil && err != io.EOF {
+			it.err = err
+		}
+		return false
+	}
+
+	var raw json.RawMessage
+	if err := it.dec.Decode(&raw); err != nil {
+		it.err = err
+		return false
+	}
+	it.raw = raw
+	return true
+}
+
+// objFrame tracks one currently-open object or array in the token stream.
+// expectKey is only meaningful for objects: it says whether the next
+// string token is a key (as opposed to a value). isHitsContainer marks the
+// object that was the value of the document's top-level "hits" key, so the
+// nested "hits" key is only matched inside that specific object -- not
+// inside some unrelated sibling object that happens to have its own field
+// named "hits" at the same nesting depth.
+type objFrame struct {
+	isObject        bool
+	expectKey       bool
+	isHitsContainer bool
+}
+
+// descend walks the token stream, tracking object/array nesting so that a
+// "hits" string token is only treated as a match when it actually occupies
+// a key position inside the right object -- not when "hits" merely appears
+// as some field's value, or as an unrelated key at the same depth, ahead of
+// the real hits.hits key. It stops once it has consumed the '[' that opens
+// the nested hits.hits array.
+func (it *HitsIterator) descend() bool {
+	var stack []objFrame
+	depth := func() int { return len(stack) }
+	expectHitsContainer := false
+
+	for {
+		tok, err := it.dec.Token()
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{':
+				frame := objFrame{isObject: true, expectKey: true, isHitsContainer: expectHitsContainer}
+				expectHitsContainer = false
+				stack = append(stack, frame)
+			case '[':
+				stack = append(stack, objFrame{isObject: false})
+			case '}', ']':
+				stack = stack[:len(stack)-1]
+				it.consumedValue(stack)
+			}
+			continue
+		}
+
+		if depth() == 0 {
+			// A scalar at the document root; not relevant to hits.hits.
+			continue
+		}
+
+		top := &stack[depth()-1]
+		if key, ok := tok.(string); ok && top.isObject && top.expectKey {
+			top.expectKey = false
+			if key == "hits" {
+				if top.isHitsContainer {
+					// This is hits.hits itself.
+					delimTok, err := it.dec.Token()
+					if err != nil {
+						it.err = err
+						return false
+					}
+					if d, ok := delimTok.(json.Delim); !ok || d != '[' {
+						it.err = fmt.Errorf("esutil: expected hits.hits to be an array")
+						return false
+					}
+					it.entered = true
+					return true
+				}
+				if depth() == 1 {
+					// The outer hits object; tag it so its own "hits" key
+					// (and only its own) is recognized above.
+					expectHitsContainer = true
+				}
+			}
+			continue
+		}
+
+		// A value token (string/number/bool/null). Mark the key consumed
+		// if we're inside an object.
+		it.consumedValue(stack)
+	}
+}
+
+// consumedValue flips the enclosing object frame back to expecting a key,
+// if any; arrays don't alternate key/value so they're left alone. It's
+// also called after a nested container closes, since closing a container
+// is itself "the value" for whatever key (or array slot) opened it.
+func (it *HitsIterator) consumedValue(stack []objFrame) {
+	if len(stack) == 0 {
+		return
+	}
+	top := &stack[len(stack)-1]
+	if top.isObject {
+		top.expectKey = true
+	}
+}
+
+// Decode unmarshals the current hit (as positioned by the last successful
+// Next) into v.
+func (it *HitsIterator) Decode(v interface{}) error {
+	if it.raw == nil {
+		return fmt.Errorf("esutil: Decode called before a successful Next")
+	}
+	return json.Unmarshal(it.raw, v)
+}
+
+// Err returns the first error encountered by Next, if any.
+func (it *HitsIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,89 @@
+package esutil
+
+import (
+	"strings"
+	"testing"
+)
+
+type iterDoc struct {
+	Username string `json:"username"`
+}
+
+func TestHitsIteratorDecodesHits(t *testing.T) {
+	body := `{
+		"took": 5,
+		"hits": {
+			"total": {"value": 2, "relation": "eq"},
+			"hits": [
+				{"_id": "1", "_source": {"username": "alice"}},
+				{"_id": "2", "_source": {"username": "bob"}}
+			]
+		}
+	}`
+
+	it := NewHitsIterator(strings.NewReader(body))
+
+	var got []string
+	for it.Next() {
+		var raw struct {
+			Source iterDoc `json:"_source"`
+		}
+		if err := it.Decode(&raw); err != nil {
+			t.Fatalf("Decode: %s", err)
+		}
+		got = append(got, raw.Source.Username)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Err: %s", err)
+	}
+	if len(got) != 2 || got[0] != "alice" || got[1] != "bob" {
+		t.Fatalf("unexpected hits: %v", got)
+	}
+}
+
+// TestHitsIteratorIgnoresHitsAsAFieldValue reproduces the bug where "hits"
+// appearing as a plain string value ahead of the real hits.hits key was
+// mistaken for the key itself, since the original implementation matched on
+// the raw token rather than on its position in the object.
+func TestHitsIteratorIgnoresHitsAsAFieldValue(t *testing.T) {
+	body := `{
+		"took": 1,
+		"decoy": "hits",
+		"nested": {"another_decoy": "hits", "hits": "not the real one either"},
+		"hits": {
+			"total": 1,
+			"hits": [
+				{"_id": "1", "_source": {"username": "carol"}}
+			]
+		}
+	}`
+
+	it := NewHitsIterator(strings.NewReader(body))
+
+	var got []string
+	for it.Next() {
+		var raw struct {
+			Source iterDoc `json:"_source"`
+		}
+		if err := it.Decode(&raw); err != nil {
+			t.Fatalf("Decode: %s", err)
+		}
+		got = append(got, raw.Source.Username)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Err: %s", err)
+	}
+	if len(got) != 1 || got[0] != "carol" {
+		t.Fatalf("expected exactly one hit for carol, got %v", got)
+	}
+}
+
+func TestHitsIteratorEmptyHits(t *testing.T) {
+	it := NewHitsIterator(strings.NewReader(`{"took": 1, "hits": {"total": 0, "hits": []}}`))
+	if it.Next() {
+		t.Fatal("expected no hits")
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Err: %s", err)
+	}
+}
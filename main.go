@@ -10,30 +10,65 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 	"reflect"
 	"strings"
-	"sync"
+	"time"
 
-	"github.com/elastic/go-elasticsearch/esapi"
 	"github.com/elastic/go-elasticsearch/v7"
+
+	"github.com/alertsm/elastic_client/esapi/typedsearch"
+	"github.com/alertsm/elastic_client/esutil"
+	"github.com/alertsm/elastic_client/pptransport"
 )
 
 func main() {
 	log.SetFlags(0)
 
-	var (
-		r  map[string]interface{}
-		wg sync.WaitGroup
-	)
+	ctx := context.Background()
+	var r map[string]interface{}
+	addrs := []string{"http://127.0.0.1:9200"}
+
+	// On Elastic Cloud, CloudID replaces Addresses -- the client rejects a
+	// Config with both set.
+	cloudID := os.Getenv("ELASTIC_CLOUD_ID")
+	if cloudID != "" {
+		addrs = nil
+	}
+
+	// pptransport is the Transport the client's own node pool calls to
+	// perform the actual HTTP round trip, so the request it sees on each
+	// attempt already has its URL rewritten to the node the client selected
+	// for that attempt.
 	elasticConf := elasticsearch.Config{
-		Addresses: []string{"http://127.0.0.1:9200"},
+		Addresses: addrs,
 		Username:  "elastic",
 		Password:  "1234!@#$",
+
+		// CloudID, if set, replaces Addresses (addrs is cleared above); APIKey,
+		// if set, takes precedence over Username/Password -- the client
+		// resolves that precedence itself, no separate auth wiring needed.
+		CloudID: cloudID,
+		APIKey:  os.Getenv("ELASTIC_API_KEY"),
+
+		// Node sniffing, dead-node backoff, and retries are handled by the
+		// client's own pool; no need to reimplement them here.
+		DiscoverNodesOnStart:  true,
+		DiscoverNodesInterval: 5 * time.Minute,
+		MaxRetries:            3,
+		RetryOnStatus:         []int{502, 503, 504},
+
+		Transport: pptransport.NewTransport(pptransport.Config{
+			Addresses: addrs,
+			// Swap pptransport.NoopTracer{} for an OpenTelemetry or Pinpoint
+			// Tracer implementation to ship spans to a real backend.
+			Tracer:     pptransport.NoopTracer{},
+			EnableGzip: true,
+		}),
 	}
 
 	// Initialize a client with the default settings.
@@ -45,6 +80,11 @@ func main() {
 		log.Fatalf("Error creating the client: %s", err)
 	}
 
+	indexer, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{Client: es})
+	if err != nil {
+		log.Fatalf("Error creating the bulk indexer: %s", err)
+	}
+
 	// 1. Get cluster info
 	//
 	res, err := es.Info()
@@ -67,8 +107,6 @@ func main() {
 
 	// 2. Search for the indexed documents
 	//
-	// Build the request body.
-	var buf bytes.Buffer
 	query := map[string]interface{}{
 		"query": map[string]interface{}{
 			"term": map[string]interface{}{
@@ -76,104 +114,54 @@ func main() {
 			},
 		},
 	}
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		log.Fatalf("Error encoding query: %s", err)
-	}
 
-	// Perform the search request.
-	res, err = es.Search(
-		es.Search.WithContext(context.Background()),
-		es.Search.WithIndex(".logstash"),
-		es.Search.WithBody(&buf),
-		es.Search.WithTrackTotalHits(true),
-		es.Search.WithPretty(),
-	)
+	result, err := typedsearch.Search[map[string]interface{}](ctx, es, ".logstash", query)
 	if err != nil {
-		log.Fatalf("Error getting response: %s", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		var e map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
-			log.Fatalf("Error parsing the response body: %s", err)
-		} else {
-			// Print the response status and error information.
-			log.Fatalf("[%s] %s: %s",
-				res.Status(),
-				e["error"].(map[string]interface{})["type"],
-				e["error"].(map[string]interface{})["reason"],
-			)
+		if esErr, ok := err.(*typedsearch.ESError); ok {
+			log.Fatalf("[%d] %s: %s", esErr.Status, esErr.Type, esErr.Reason)
 		}
+		log.Fatalf("Error getting response: %s", err)
 	}
-
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Fatalf("Error parsing the response body: %s", err)
-	}
-	// Print the response status, number of results, and request duration.
-	log.Printf(
-		"[%s] %d hits; took: %dms",
-		res.Status(),
-		int(r["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64)),
-		int(r["took"].(float64)),
-	)
+	// Print the number of results and request duration.
+	log.Printf("%d hits; took: %s", result.TotalHits, result.Took)
 	// Print the ID and document source for each hit.
-	for _, hit := range r["hits"].(map[string]interface{})["hits"].([]interface{}) {
-		source := hit.(map[string]interface{})["_source"].(map[string]interface{})
-		id := fmt.Sprintf("%v", hit.(map[string]interface{})["_id"])
-		log.Printf("before username=[%v]", hit.(map[string]interface{})["username1"])
+	for _, hit := range result.Hits {
+		source := hit.Source
+		id := hit.ID
 		username, ok := source["username"]
 		log.Printf("username_type=%s", reflect.ValueOf(username).Type())
-		if reflect.ValueOf(hit.(map[string]interface{})["_id"]).String() == "main1" {
+		if id == "main1" {
 			log.Printf("username=%v, len=%v", reflect.ValueOf(username), ok)
-			//if ok {
-			inindex := fmt.Sprintf("%v", hit.(map[string]interface{})["_index"])
-			log.Printf("index=%s %s", inindex, source)
+			log.Printf("index=%s %s", hit.Index, source)
 			source["pipeline"] = `##testpipeline2
 			` + fmt.Sprintf("%v", source["pipeline"])
-			// 2. Index documents concurrently
+			// 2. Index the document via the bulk indexer.
 			//
-			wg.Add(1)
-
-			go func(str string) {
-				defer wg.Done()
-
-				// Build the request body.
-				var b strings.Builder
-				output, _ := json.Marshal(source)
-				log.Printf("output=%s", output)
-				b.WriteString(string(output))
-
-				// Set up the request object.
-				req := esapi.IndexRequest{
-					Index:      "test",
-					DocumentID: id,
-					Body:       strings.NewReader(b.String()),
-					Refresh:    "true",
-				}
-
-				// Perform the request with the client.
-				res, err := req.Do(context.Background(), es)
-				if err != nil {
-					log.Fatalf("Error getting response: %s", err)
-				}
-				defer res.Body.Close()
-
-				if res.IsError() {
-					log.Printf("[%s] Error indexing document ID=%s", res.Status(), id)
-				} else {
-					// Deserialize the response into a map.
-					var r map[string]interface{}
-					if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-						log.Printf("Error parsing the response body: %s", err)
+			output, _ := json.Marshal(source)
+			log.Printf("output=%s", output)
+
+			if err := indexer.Add(ctx, esutil.BulkIndexerItem{
+				Action:     "index",
+				Index:      "test",
+				DocumentID: id,
+				Body:       strings.NewReader(string(output)),
+				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
+					log.Printf("[%d] %s; version ID=%s", res.Status, res.Result, item.DocumentID)
+				},
+				OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+					if err != nil {
+						log.Printf("Error indexing document ID=%s: %s", item.DocumentID, err)
 					} else {
-						// Print the response status and indexed document version.
-						log.Printf("[%s] %s; version=%d", res.Status(), r["result"], int(r["_version"].(float64)))
+						log.Printf("[%d] Error indexing document ID=%s: %s", res.Status, item.DocumentID, res.Error.Reason)
 					}
-				}
-			}("t")
-
-			wg.Wait()
+				},
+			}); err != nil {
+				log.Fatalf("Error adding item to bulk indexer: %s", err)
+			}
+
+			if err := indexer.Close(ctx); err != nil {
+				log.Fatalf("Error closing bulk indexer: %s", err)
+			}
 
 			log.Println(strings.Repeat("-", 37))
 		}
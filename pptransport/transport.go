@@ -0,0 +1,184 @@
+// Package pptransport provides an http.RoundTripper wrapper that emits a
+// trace span for every request made by the Elasticsearch client, with
+// optional gzip negotiation and a response-body interceptor hook.
+//
+// Plug it in via elasticsearch.Config.Transport -- the client's own node
+// pool calls Config.Transport to perform the actual HTTP round trip for
+// whichever node it selected, so the request this transport sees already
+// has its URL rewritten to that node:
+//
+//	es, err := elasticsearch.NewClient(elasticsearch.Config{
+//		Addresses: addrs,
+//		Transport: pptransport.NewTransport(pptransport.Config{
+//			Addresses: addrs,
+//			Tracer:    pptransport.NoopTracer{},
+//		}),
+//	})
+//
+// Spans are started from the request's own context, so calls made with
+// es.Search.WithContext(ctx) or req.Do(ctx, es) that share a parent context
+// produce properly nested spans -- e.g. an IndexRequest fired from a
+// goroutine spawned while handling a Search response becomes a child of
+// that search's span, matching the pattern used by the pinpoint-go-agent
+// goelastic plugin.
+package pptransport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+// Span represents a single outgoing Elasticsearch request. Tracer
+// implementations (OpenTelemetry, Pinpoint, or a custom backend) return a
+// Span from Start and receive a call to Finish once the response (or
+// transport error) is available.
+type Span interface {
+	// Finish records the outcome of the request. err is the error returned
+	// by RoundTrip, if any; statusCode is the response's status, or 0 when
+	// there is no response (the base RoundTripper failed, or
+	// ResponseInterceptor returned a nil response).
+	Finish(statusCode int, err error)
+}
+
+// Tracer starts a Span for an outgoing request. Implementations should tag
+// the span with at least db.system=elasticsearch, http.method, http.url and
+// db.statement (body, truncated to MaxBodyCapture), reading the parent span
+// off req.Context().
+type Tracer interface {
+	Start(req *http.Request, body []byte) Span
+}
+
+// NoopTracer is a Tracer that records nothing. It is the default used by
+// NewTransport when Config.Tracer is nil, so instrumentation can be wired
+// in later without touching call sites.
+type NoopTracer struct{}
+
+type noopSpan struct{}
+
+func (noopSpan) Finish(int, error) {}
+
+// Start implements Tracer.
+func (NoopTracer) Start(*http.Request, []byte) Span {
+	return noopSpan{}
+}
+
+// MaxBodyCapture bounds how much of a request body is copied into
+// db.statement before it is handed to the Tracer, so large bulk payloads
+// don't blow up span storage.
+const MaxBodyCapture = 4096
+
+// Config configures a pptransport RoundTripper.
+type Config struct {
+	// Base is the underlying RoundTripper. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// Addresses is the configured set of cluster addresses, for Tracer
+	// implementations that want a cluster-level label; the node actually hit
+	// for a given request is req.URL, which reflects that node's address as
+	// long as this transport sits below any node-selecting layer (see the
+	// package doc).
+	Addresses []string
+	// Tracer receives a Span per request. Defaults to NoopTracer.
+	Tracer Tracer
+
+	// EnableGzip sends "Accept-Encoding: gzip" on every request and
+	// transparently unwraps a gzip-encoded response body before it reaches
+	// the caller (and before ResponseInterceptor runs).
+	EnableGzip bool
+	// ResponseInterceptor, if set, is called with every response this
+	// transport returns, after gzip unwrapping and before the span is
+	// finished. It may inspect or replace res.Body (e.g. to log or
+	// re-buffer it) but must return a Body the caller can still read.
+	ResponseInterceptor func(res *http.Response) (*http.Response, error)
+}
+
+// transport wraps a base http.RoundTripper and emits a Span for every
+// request it proxies.
+type transport struct {
+	config Config
+}
+
+// NewTransport returns an http.RoundTripper configured by cfg.
+func NewTransport(cfg Config) http.RoundTripper {
+	if cfg.Base == nil {
+		cfg.Base = http.DefaultTransport
+	}
+	if cfg.Tracer == nil {
+		cfg.Tracer = NoopTracer{}
+	}
+	return &transport{config: cfg}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var captured []byte
+	if req.Body != nil {
+		full, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(full))
+		captured = full
+		if len(captured) > MaxBodyCapture {
+			captured = captured[:MaxBodyCapture]
+		}
+	}
+
+	if t.config.EnableGzip {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	span := t.config.Tracer.Start(req, captured)
+
+	res, err := t.config.Base.RoundTrip(req)
+	if err != nil {
+		span.Finish(0, err)
+		return res, err
+	}
+
+	if t.config.EnableGzip && res.Header.Get("Content-Encoding") == "gzip" {
+		if res.Body, err = newGzipUnwrapper(res.Body); err != nil {
+			span.Finish(res.StatusCode, err)
+			return res, err
+		}
+		res.Header.Del("Content-Encoding")
+	}
+
+	if t.config.ResponseInterceptor != nil {
+		if res, err = t.config.ResponseInterceptor(res); err != nil {
+			status := 0
+			if res != nil {
+				status = res.StatusCode
+			}
+			span.Finish(status, err)
+			return res, err
+		}
+	}
+
+	span.Finish(res.StatusCode, nil)
+	return res, nil
+}
+
+// gzipUnwrapper wraps a gzip.Reader so that closing it also closes the
+// underlying response body.
+type gzipUnwrapper struct {
+	*gzip.Reader
+	orig io.ReadCloser
+}
+
+func newGzipUnwrapper(body io.ReadCloser) (io.ReadCloser, error) {
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	return &gzipUnwrapper{Reader: zr, orig: body}, nil
+}
+
+func (g *gzipUnwrapper) Close() error {
+	g.Reader.Close()
+	return g.orig.Close()
+}
@@ -0,0 +1,99 @@
+package pptransport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransportUnwrapsGzipResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Error("expected Accept-Encoding: gzip to be set")
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte("hello"))
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	rt := NewTransport(Config{EnableGzip: true})
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %s", err)
+	}
+	defer res.Body.Close()
+
+	if enc := res.Header.Get("Content-Encoding"); enc != "" {
+		t.Fatalf("expected Content-Encoding to be stripped after unwrapping, got %q", enc)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("ReadAll: %s", err)
+	}
+	if string(body) != "hello" {
+		t.Fatalf("expected unwrapped body %q, got %q", "hello", body)
+	}
+}
+
+func TestTransportCallsResponseInterceptor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("original"))
+	}))
+	defer srv.Close()
+
+	var seen []byte
+	rt := NewTransport(Config{
+		ResponseInterceptor: func(res *http.Response) (*http.Response, error) {
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				return nil, err
+			}
+			res.Body.Close()
+			seen = body
+			res.Body = io.NopCloser(bytes.NewReader(body))
+			return res, nil
+		},
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %s", err)
+	}
+	defer res.Body.Close()
+
+	if string(seen) != "original" {
+		t.Fatalf("expected ResponseInterceptor to observe %q, got %q", "original", seen)
+	}
+	body, _ := io.ReadAll(res.Body)
+	if string(body) != "original" {
+		t.Fatalf("expected caller to still be able to read the body, got %q", body)
+	}
+}
+
+func TestTransportResponseInterceptorNilResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("original"))
+	}))
+	defer srv.Close()
+
+	rt := NewTransport(Config{
+		ResponseInterceptor: func(res *http.Response) (*http.Response, error) {
+			return nil, errors.New("boom")
+		},
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	_, err := rt.RoundTrip(req)
+	if err == nil {
+		t.Fatal("expected RoundTrip to return the interceptor's error")
+	}
+}